@@ -3,12 +3,56 @@ package main
 import (
 	"log"
 	"math"
+	"math/cmplx"
 	"runtime"
 	"sync"
 )
 
+// InterpMode selects how gap-filling interpolation between two emitted points is computed.
+type InterpMode int
+
+const (
+	// InterpLinear interpolates along the straight chord between the two points.
+	InterpLinear InterpMode = iota
+	// InterpPolar interpolates along a logarithmic spiral between the two points, which
+	// tracks spiraling traces (e.g. zeta partial sums) far more faithfully than a chord.
+	InterpPolar
+)
+
+// interpolatePoint returns the point at parameter t (0,1) between a and b using mode.
+func interpolatePoint(a, b complex128, t float64, mode InterpMode) complex128 {
+	if mode == InterpPolar {
+		return polarInterp(a, b, t)
+	}
+	return a*(1-complex(t, 0)) + b*complex(t, 0)
+}
+
+// polarInterp interpolates from a to b along the logarithmic spiral that carries a to b,
+// i.e. the path whose argument and log-magnitude both vary linearly with t. This follows
+// the true arc of a spiraling trace instead of cutting a straight chord across it.
+func polarInterp(a, b complex128, t float64) complex128 {
+	if a == 0 || b == 0 {
+		// No direction to spiral around when either endpoint is the origin; falling back to
+		// linear interpolation avoids math.Log(0) = -Inf collapsing every t in (0,1) to 0.
+		return a*(1-complex(t, 0)) + b*complex(t, 0)
+	}
+	if cmplx.Abs(a) < cmplx.Abs(b) {
+		// Interpolate from the larger-magnitude endpoint for numerical accuracy.
+		return polarInterp(b, a, 1-t)
+	}
+
+	z := b / a
+	zArg := cmplx.Phase(z) // in [-pi, pi]; -pi is mapped to pi below so the range is (-pi, pi]
+	if zArg == -math.Pi {
+		zArg = math.Pi
+	}
+	zLogAbs := math.Log(cmplx.Abs(z))
+
+	return a * cmplx.Rect(math.Exp(t*zLogAbs), t*zArg)
+}
+
 // downsampleComplexSerial is the original serial version of the downsampling algorithm
-func downsampleComplexSerial(links []complex128, outputSize int, aggressiveness float64, debug bool) []complex128 {
+func downsampleComplexSerial(links []complex128, outputSize int, aggressiveness float64, mode InterpMode, debug bool) []complex128 {
 	if len(links) == 0 {
 		return links
 	}
@@ -55,12 +99,6 @@ func downsampleComplexSerial(links []complex128, outputSize int, aggressiveness
 		maxRelativeSpread = 0.03 + (0.02 * t)
 	}
 
-	// Also consider pixel-space proximity for grouping
-	pixelSpreadThreshold := 1.0
-	if aggressiveness > 0.0 {
-		pixelSpreadThreshold += (aggressiveness * 2.0)
-	}
-
 	// If the relative spread is small enough, average all points
 	if relativeSpread <= maxRelativeSpread {
 		var sum complex128
@@ -71,97 +109,15 @@ func downsampleComplexSerial(links []complex128, outputSize int, aggressiveness
 		return []complex128{avg}
 	}
 
-	// Helper to compute pixel coordinate for a link
-	pixelForLink := func(link complex128) (int, int) {
-		normalizedX := (real(link) - minX) / (maxX - minX)
-		normalizedY := (imag(link) - minY) / (maxY - minY)
-		px := int(math.Round(normalizedX * float64(outputSize)))
-		py := int(math.Round(normalizedY * float64(outputSize)))
-		return px, py
-	}
-
-	// Calculate interpolation threshold based on aggressiveness
-	interpolationThreshold := 1.1 * math.Pow(2.5, aggressiveness)
-	if aggressiveness > 3.5 {
-		t := (aggressiveness - 3.5) / 0.5
-		interpolationThreshold = 55.0 + (20.0 * t)
-	}
-
-	var downsampled []complex128
-	type groupData struct {
-		sum      complex128
-		count    int
-		pixelX   int
-		pixelY   int
-		lastLink complex128
-	}
-
-	// Initialize with first point
-	initPx, initPy := pixelForLink(links[0])
-	currentGroup := groupData{
-		sum:      links[0],
-		count:    1,
-		pixelX:   initPx,
-		pixelY:   initPy,
-		lastLink: links[0],
-	}
-
-	// Helper to flush a group
-	flushGroup := func(g groupData) complex128 {
-		return g.sum / complex(float64(g.count), 0)
-	}
-
-	// Process all points sequentially
-	for i := 1; i < len(links); i++ {
-		link := links[i]
-		px, py := pixelForLink(link)
-
-		// Check if this point belongs to current group
-		if px == currentGroup.pixelX && py == currentGroup.pixelY ||
-			(math.Abs(float64(px-currentGroup.pixelX)) <= pixelSpreadThreshold &&
-				math.Abs(float64(py-currentGroup.pixelY)) <= pixelSpreadThreshold) {
-			currentGroup.sum += link
-			currentGroup.count++
-			currentGroup.lastLink = link
-			continue
-		}
-
-		// Group changed: flush current group
-		avg := flushGroup(currentGroup)
-		downsampled = append(downsampled, avg)
-
-		// Check for interpolation
-		dx := px - currentGroup.pixelX
-		dy := py - currentGroup.pixelY
-		pixelGap := math.Sqrt(float64(dx*dx + dy*dy))
-
-		if pixelGap > interpolationThreshold {
-			steps := int(pixelGap / math.Pow(2, math.Min(aggressiveness, 3.5)))
-			if aggressiveness > 3.5 {
-				t := (aggressiveness - 3.5) / 0.5
-				steps = int(float64(steps) * (1.0 - (0.5 * t)))
-			}
-
-			for s := 1; s <= steps; s++ {
-				t := float64(s) / float64(steps+1)
-				interp := currentGroup.lastLink*(1-complex(t, 0)) + link*complex(t, 0)
-				downsampled = append(downsampled, interp)
-			}
-		}
-
-		// Start new group
-		currentGroup = groupData{
-			sum:      link,
-			count:    1,
-			pixelX:   px,
-			pixelY:   py,
-			lastLink: link,
-		}
+	// The actual grouping/interpolation pass is delegated to the streaming core; this function
+	// just feeds it from a slice instead of an io.Reader. The workers in
+	// downsampleComplexParallel run their own copy of the same grouping loop.
+	d := NewStreamingDownsampler(outputSize, Rect{MinX: minX, MaxX: maxX, MinY: minY, MaxY: maxY}, aggressiveness)
+	d.mode = mode
+	for _, link := range links {
+		d.Write(link)
 	}
-
-	// Flush final group
-	finalAvg := flushGroup(currentGroup)
-	downsampled = append(downsampled, finalAvg)
+	downsampled := d.Flush()
 
 	if debug {
 		log.Printf("Downsampled %d points to %d points", len(links), len(downsampled))
@@ -171,14 +127,25 @@ func downsampleComplexSerial(links []complex128, outputSize int, aggressiveness
 
 // downsampleComplex uses the view bounds (computed from all links) and the output image size,
 // so that only links that fall within the same pixel are averaged. Additionally, if two adjacent
-// groups are separated by more than one pixel, it linearly interpolates extra points.
+// groups are separated by more than one pixel, it interpolates extra points using mode.
 // aggressiveness controls how much reduction to do (0.0 = minimal, 1.0 = maximum)
-func downsampleComplex(links []complex128, outputSize int, aggressiveness float64, debug bool) []complex128 {
-
+// Unlike downsampleComplexFiltered (which buckets links by their exact pixel), groups here
+// absorb consecutive links whose pixel lies within pixelSpreadThreshold of the group's pixel
+// (1.0 at aggressiveness 0, growing with aggressiveness), so the two are not interchangeable
+// at the same aggressiveness; use downsampleComplexFiltered directly for Triangle/Bicubic/Gaussian/Lanczos3
+// reconstruction.
+func downsampleComplex(links []complex128, outputSize int, aggressiveness float64, mode InterpMode, debug bool) []complex128 {
 	// There is not much point in parallelizing for small numbers of links - benefits are minimal
 	if len(links) < 10000 {
-		return downsampleComplexSerial(links, outputSize, aggressiveness, debug)
+		return downsampleComplexSerial(links, outputSize, aggressiveness, mode, debug)
 	}
+	return downsampleComplexParallel(links, outputSize, aggressiveness, mode, debug, runtime.NumCPU())
+}
+
+// downsampleComplexParallel is downsampleComplex's chunked implementation with the worker
+// count exposed, so tests can check that the merge logic is invariant to how the links are
+// split regardless of how many CPUs the machine running it happens to have.
+func downsampleComplexParallel(links []complex128, outputSize int, aggressiveness float64, mode InterpMode, debug bool, numWorkers int) []complex128 {
 
 	if debug {
 		log.Printf("Starting downsampleComplex with %d links and output size %d (aggressiveness: %.2f)",
@@ -270,16 +237,39 @@ func downsampleComplex(links []complex128, outputSize int, aggressiveness float6
 		interpolationThreshold = 55.0 + (20.0 * t)
 	}
 
-	// Process chunks in parallel
-	numWorkers := runtime.NumCPU()
+	// Process chunks in parallel. A worker never knows whether its first or last
+	// group would, in the serial version, have kept absorbing points from the
+	// neighboring chunk, so it leaves those two groups unflushed (boundaryGroup)
+	// and appends only its interior points to its own output. The collector below
+	// fuses adjacent boundary groups as the serial loop would have; when a chunk's
+	// leading group merges into the previous trailing group, it drops that chunk's
+	// interior and re-walks its links sequentially (redoChunk), recovering
+	// bit-identical output regardless of worker count.
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
 	chunkSize := (len(links) + numWorkers - 1) / numWorkers
 
+	// boundaryGroup mirrors groupData but also tracks the raw first link, which
+	// the serial algorithm uses as the interpolation endpoint when a group that
+	// turns out not to be merged still needs a gap bridged to its neighbor.
+	type boundaryGroup struct {
+		sum       complex128
+		count     int
+		pixelX    int
+		pixelY    int
+		firstLink complex128
+		lastLink  complex128
+	}
+
 	type chunkResult struct {
-		index     int
-		points    []complex128
-		lastPoint complex128
-		lastPx    int
-		lastPy    int
+		index       int
+		hasData     bool
+		singleGroup bool // whole chunk collapsed into one group: leading == trailing
+		start, end  int  // raw link range, kept so the collector can re-walk it if needed
+		leading     boundaryGroup
+		trailing    boundaryGroup
+		interior    []complex128
 	}
 
 	results := make(chan chunkResult, numWorkers)
@@ -302,8 +292,6 @@ func downsampleComplex(links []complex128, outputSize int, aggressiveness float6
 				return
 			}
 
-			// Initialize chunk processing
-			var chunkPoints []complex128
 			type groupData struct {
 				sum      complex128
 				count    int
@@ -312,7 +300,19 @@ func downsampleComplex(links []complex128, outputSize int, aggressiveness float6
 				lastLink complex128
 			}
 
-			// Start with the first point in the chunk
+			toBoundary := func(g groupData, first complex128) boundaryGroup {
+				return boundaryGroup{
+					sum:       g.sum,
+					count:     g.count,
+					pixelX:    g.pixelX,
+					pixelY:    g.pixelY,
+					firstLink: first,
+					lastLink:  g.lastLink,
+				}
+			}
+
+			// Start with the first point in the chunk. This is the leading group;
+			// whether it stays separate or merges backward is decided by the collector.
 			initPx, initPy := pixelForLink(links[start])
 			currentGroup := groupData{
 				sum:      links[start],
@@ -321,6 +321,12 @@ func downsampleComplex(links []complex128, outputSize int, aggressiveness float6
 				pixelY:   initPy,
 				lastLink: links[start],
 			}
+			leadingFirst := links[start]
+			groupFirst := leadingFirst
+
+			var interior []complex128
+			var leading boundaryGroup
+			haveLeading := false
 
 			// Helper to flush a group
 			flushGroup := func(g groupData) complex128 {
@@ -342,9 +348,15 @@ func downsampleComplex(links []complex128, outputSize int, aggressiveness float6
 					continue
 				}
 
-				// Group changed: flush current group
-				avg := flushGroup(currentGroup)
-				chunkPoints = append(chunkPoints, avg)
+				// Group changed: the first group formed is the leading group and is left
+				// unflushed for the collector; later groups flush normally.
+				if !haveLeading {
+					leading = toBoundary(currentGroup, leadingFirst)
+					haveLeading = true
+				} else {
+					avg := flushGroup(currentGroup)
+					interior = append(interior, avg)
+				}
 
 				// Check for interpolation
 				dx := px - currentGroup.pixelX
@@ -360,8 +372,8 @@ func downsampleComplex(links []complex128, outputSize int, aggressiveness float6
 
 					for s := 1; s <= steps; s++ {
 						t := float64(s) / float64(steps+1)
-						interp := currentGroup.lastLink*(1-complex(t, 0)) + link*complex(t, 0)
-						chunkPoints = append(chunkPoints, interp)
+						interp := interpolatePoint(currentGroup.lastLink, link, t, mode)
+						interior = append(interior, interp)
 					}
 				}
 
@@ -373,19 +385,26 @@ func downsampleComplex(links []complex128, outputSize int, aggressiveness float6
 					pixelY:   py,
 					lastLink: link,
 				}
+				groupFirst = link
 			}
 
-			// Flush final group
-			finalAvg := flushGroup(currentGroup)
-			chunkPoints = append(chunkPoints, finalAvg)
+			trailing := toBoundary(currentGroup, groupFirst)
 
-			results <- chunkResult{
-				index:     worker,
-				points:    chunkPoints,
-				lastPoint: currentGroup.lastLink,
-				lastPx:    currentGroup.pixelX,
-				lastPy:    currentGroup.pixelY,
+			result := chunkResult{
+				index:       worker,
+				hasData:     true,
+				singleGroup: !haveLeading,
+				start:       start,
+				end:         end,
+				trailing:    trailing,
+				interior:    interior,
+			}
+			if haveLeading {
+				result.leading = leading
+			} else {
+				result.leading = trailing
 			}
+			results <- result
 		}(w, start, end)
 	}
 
@@ -395,57 +414,109 @@ func downsampleComplex(links []complex128, outputSize int, aggressiveness float6
 		close(results)
 	}()
 
-	// Collect and merge results
-	type collectedResult struct {
-		points    []complex128
-		lastPoint complex128
-		lastPx    int
-		lastPy    int
+	collected := make([]chunkResult, numWorkers)
+	for result := range results {
+		collected[result.index] = result
 	}
-	collected := make([]collectedResult, numWorkers)
 
-	// Collect all results
-	for result := range results {
-		collected[result.index] = collectedResult{
-			points:    result.points,
-			lastPoint: result.lastPoint,
-			lastPx:    result.lastPx,
-			lastPy:    result.lastPy,
+	flushBoundary := func(g boundaryGroup) complex128 {
+		return g.sum / complex(float64(g.count), 0)
+	}
+
+	// canMerge applies the same pixel-proximity rule the serial loop uses to decide
+	// whether a point belongs to the current group, but between two groups.
+	canMerge := func(a, b boundaryGroup) bool {
+		return a.pixelX == b.pixelX && a.pixelY == b.pixelY ||
+			(math.Abs(float64(b.pixelX-a.pixelX)) <= pixelSpreadThreshold &&
+				math.Abs(float64(b.pixelY-a.pixelY)) <= pixelSpreadThreshold)
+	}
+
+	// redoChunk re-walks a chunk's raw links sequentially, continuing an already-open group
+	// (seed) exactly as the serial algorithm would. It exists because a worker cannot know,
+	// while grouping its own chunk, whether its leading group actually continues a group
+	// seeded back in an earlier chunk: it grouped every point against its own first point's
+	// pixel, but if pending's real seed pixel differs (even while still within
+	// pixelSpreadThreshold, which is why canMerge allows the fusion), the threshold test
+	// isn't transitive - points after the first may have been (mis)grouped against the
+	// wrong seed, and that can cascade through every group the worker formed afterward.
+	// Re-walking against the true seed is the only way to reproduce serial's output exactly;
+	// it costs this one chunk its parallelism.
+	bounds := Rect{MinX: minX, MaxX: maxX, MinY: minY, MaxY: maxY}
+	redoChunk := func(start, end int, seed boundaryGroup) ([]complex128, boundaryGroup) {
+		d := NewStreamingDownsampler(outputSize, bounds, aggressiveness)
+		d.mode = mode
+		d.started = true
+		d.currentGroup = streamGroup{sum: seed.sum, count: seed.count, pixelX: seed.pixelX, pixelY: seed.pixelY, lastLink: seed.lastLink}
+
+		for _, link := range links[start:end] {
+			d.Write(link)
 		}
+
+		out := d.pending
+		newPending := boundaryGroup{
+			sum:      d.currentGroup.sum,
+			count:    d.currentGroup.count,
+			pixelX:   d.currentGroup.pixelX,
+			pixelY:   d.currentGroup.pixelY,
+			lastLink: d.currentGroup.lastLink,
+		}
+		return out, newPending
+	}
+
+	appendGapInterp := func(dst []complex128, a, b boundaryGroup) []complex128 {
+		dx := b.pixelX - a.pixelX
+		dy := b.pixelY - a.pixelY
+		gap := math.Sqrt(float64(dx*dx + dy*dy))
+		if gap <= interpolationThreshold {
+			return dst
+		}
+		steps := int(gap / math.Pow(2, math.Min(aggressiveness, 3.5)))
+		if aggressiveness > 3.5 {
+			t := (aggressiveness - 3.5) / 0.5
+			steps = int(float64(steps) * (1.0 - (0.5 * t)))
+		}
+		for s := 1; s <= steps; s++ {
+			t := float64(s) / float64(steps+1)
+			dst = append(dst, interpolatePoint(a.lastLink, b.firstLink, t, mode))
+		}
+		return dst
 	}
 
-	// Merge results with interpolation between chunks
 	var finalPoints []complex128
-	for i := 0; i < len(collected); i++ {
-		if len(collected[i].points) == 0 {
+	var pending *boundaryGroup // an unflushed trailing group carried from earlier chunks
+
+	for _, result := range collected {
+		if !result.hasData {
 			continue
 		}
 
-		// Add points from this chunk
-		finalPoints = append(finalPoints, collected[i].points...)
-
-		// If not the last chunk and next chunk has points, check if interpolation is needed
-		if i < len(collected)-1 && len(collected[i+1].points) > 0 {
-			// Check distance between chunks
-			dx := collected[i+1].lastPx - collected[i].lastPx
-			dy := collected[i+1].lastPy - collected[i].lastPy
-			gap := math.Sqrt(float64(dx*dx + dy*dy))
-
-			if gap > interpolationThreshold {
-				steps := int(gap / math.Pow(2, math.Min(aggressiveness, 3.5)))
-				if aggressiveness > 3.5 {
-					t := (aggressiveness - 3.5) / 0.5
-					steps = int(float64(steps) * (1.0 - (0.5 * t)))
-				}
+		leading := result.leading
 
-				nextFirstPoint := collected[i+1].points[0]
-				for s := 1; s <= steps; s++ {
-					t := float64(s) / float64(steps+1)
-					interp := collected[i].lastPoint*(1-complex(t, 0)) + nextFirstPoint*complex(t, 0)
-					finalPoints = append(finalPoints, interp)
-				}
-			}
+		if pending != nil && canMerge(*pending, leading) {
+			out, newPending := redoChunk(result.start, result.end, *pending)
+			finalPoints = append(finalPoints, out...)
+			pending = &newPending
+			continue
+		}
+
+		if pending != nil {
+			finalPoints = append(finalPoints, flushBoundary(*pending))
+			finalPoints = appendGapInterp(finalPoints, *pending, leading)
+			pending = nil
 		}
+
+		if result.singleGroup {
+			pending = &leading
+			continue
+		}
+		finalPoints = append(finalPoints, flushBoundary(leading))
+		finalPoints = append(finalPoints, result.interior...)
+		trailing := result.trailing
+		pending = &trailing
+	}
+
+	if pending != nil {
+		finalPoints = append(finalPoints, flushBoundary(*pending))
 	}
 
 	if debug {
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+// zetaLikeTrace generates a spiraling polyline reminiscent of a zeta partial-sum trace,
+// which is exactly the shape that used to expose the chunk-boundary bug: groups of nearly
+// identical points punctuated by occasional large jumps.
+func zetaLikeTrace(n int) []complex128 {
+	links := make([]complex128, n)
+	angle := 0.0
+	radius := 1.0
+	for i := 0; i < n; i++ {
+		angle += 0.01 + 0.0003*float64(i%37)
+		if i%500 == 0 {
+			radius *= 1.05
+		}
+		links[i] = cmplx.Rect(radius, angle)
+	}
+	return links
+}
+
+func TestDownsampleComplexMatchesSerialAcrossWorkerCounts(t *testing.T) {
+	inputs := map[string][]complex128{
+		"spiral-50k":    zetaLikeTrace(50000),
+		"spiral-12345":  zetaLikeTrace(12345),
+		"spiral-100001": zetaLikeTrace(100001),
+	}
+
+	for name, links := range inputs {
+		for _, numWorkers := range []int{1, 2, 3, 4, 5, 8, 16} {
+			for _, aggressiveness := range []float64{0.0, 1.0, 2.5} {
+				serial := downsampleComplexSerial(links, 512, aggressiveness, InterpLinear, false)
+				parallel := downsampleComplexParallel(links, 512, aggressiveness, InterpLinear, false, numWorkers)
+
+				if len(serial) != len(parallel) {
+					t.Fatalf("%s workers=%d aggressiveness=%.1f: serial produced %d points, parallel produced %d",
+						name, numWorkers, aggressiveness, len(serial), len(parallel))
+				}
+				for i := range serial {
+					if serial[i] != parallel[i] {
+						t.Fatalf("%s workers=%d aggressiveness=%.1f: point %d differs: serial=%v parallel=%v",
+							name, numWorkers, aggressiveness, i, serial[i], parallel[i])
+					}
+				}
+			}
+		}
+	}
+}
+
+// TestDownsampleComplexParallelGapInterpUsesClusterFirstLink guards against a regression
+// where a worker's trailing boundary group used its cluster's *last* link as the gap-interp
+// endpoint instead of its first: when that worker's whole chunk collapses into one
+// multi-point pixel-spread cluster (singleGroup) that does not merge with the previous
+// chunk's pending group, the collector interpolates the cross-chunk gap toward the wrong
+// point. Crafted explicitly (rather than relying on zetaLikeTrace) so the count>1 singleGroup
+// case is guaranteed regardless of how the generator's output happens to chunk.
+func TestDownsampleComplexParallelGapInterpUsesClusterFirstLink(t *testing.T) {
+	links := []complex128{
+		complex(0, 0), complex(0.4, 0),
+		complex(50, 0), complex(50.4, 0),
+		complex(90, 0), complex(90.4, 0),
+	}
+
+	serial := downsampleComplexSerial(links, 100, 0.0, InterpLinear, false)
+	parallel := downsampleComplexParallel(links, 100, 0.0, InterpLinear, false, 3)
+
+	if len(serial) != len(parallel) {
+		t.Fatalf("serial produced %d points, parallel produced %d", len(serial), len(parallel))
+	}
+	for i := range serial {
+		if serial[i] != parallel[i] {
+			t.Fatalf("point %d differs: serial=%v parallel=%v", i, serial[i], parallel[i])
+		}
+	}
+}
+
+func TestPolarInterpHandlesZeroAndSwap(t *testing.T) {
+	if got := polarInterp(0, complex(1, 1), 0.5); got != complex(0.5, 0.5) {
+		t.Errorf("polarInterp with a==0 should fall back to linear, got %v", got)
+	}
+	if got := polarInterp(complex(1, 1), 0, 0.5); got != complex(0.5, 0.5) {
+		t.Errorf("polarInterp with b==0 should fall back to linear, got %v", got)
+	}
+
+	a := complex(1, 0)
+	b := complex(4, 0)
+	small := polarInterp(a, b, 0.25)
+	large := polarInterp(b, a, 0.75)
+	if math.Abs(real(small)-real(large)) > 1e-9 || math.Abs(imag(small)-imag(large)) > 1e-9 {
+		t.Errorf("polarInterp(a,b,t) should equal polarInterp(b,a,1-t); got %v vs %v", small, large)
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"log"
+	"math"
+)
+
+// Resampler is a 1-D reconstruction kernel used to weight nearby samples when
+// building an output pixel, the same role played by the resize kernels in
+// image-resampling libraries (box, triangle, bicubic, Gaussian, Lanczos).
+type Resampler interface {
+	// Name identifies the kernel for logging/debugging.
+	Name() string
+	// Support returns the kernel's half-width, in output-pixel units. Only
+	// samples within this distance of the center contribute.
+	Support() float64
+	// Weight returns the kernel's weight at distance x (in output-pixel
+	// units) from the center. Only ever evaluated for |x| <= Support().
+	Weight(x float64) float64
+}
+
+// boxResampler gives each output pixel the unweighted mean of its own pixel
+// bucket: only the zero offset lies within its half-pixel support.
+type boxResampler struct{}
+
+func (boxResampler) Name() string       { return "box" }
+func (boxResampler) Support() float64   { return 0.5 }
+func (boxResampler) Weight(x float64) float64 {
+	if math.Abs(x) <= 0.5 {
+		return 1.0
+	}
+	return 0.0
+}
+
+// triangleResampler is a linear (tent) filter: weight falls off to zero at
+// one pixel away.
+type triangleResampler struct{}
+
+func (triangleResampler) Name() string     { return "triangle" }
+func (triangleResampler) Support() float64 { return 1.0 }
+func (triangleResampler) Weight(x float64) float64 {
+	x = math.Abs(x)
+	if x >= 1.0 {
+		return 0.0
+	}
+	return 1.0 - x
+}
+
+// bicubicResampler implements the Mitchell-Netravali family of cubic filters.
+// B=1/3, C=1/3 (the defaults) is the classic "Mitchell" filter; B=0, C=0.5
+// gives Catmull-Rom.
+type bicubicResampler struct {
+	B, C float64
+}
+
+func (r bicubicResampler) Name() string     { return "bicubic" }
+func (r bicubicResampler) Support() float64 { return 2.0 }
+func (r bicubicResampler) Weight(x float64) float64 {
+	x = math.Abs(x)
+	b, c := r.B, r.C
+	switch {
+	case x < 1:
+		return ((12-9*b-6*c)*x*x*x + (-18+12*b+6*c)*x*x + (6 - 2*b)) / 6
+	case x < 2:
+		return ((-b-6*c)*x*x*x + (6*b+30*c)*x*x + (-12*b-48*c)*x + (8*b + 24*c)) / 6
+	default:
+		return 0
+	}
+}
+
+// gaussianResampler is a truncated Gaussian filter.
+type gaussianResampler struct {
+	Sigma float64
+}
+
+func (r gaussianResampler) Name() string     { return "gaussian" }
+func (r gaussianResampler) Support() float64 { return 3 * r.Sigma }
+func (r gaussianResampler) Weight(x float64) float64 {
+	return math.Exp(-(x * x) / (2 * r.Sigma * r.Sigma))
+}
+
+// lanczosResampler is the Lanczos windowed-sinc filter with window radius A
+// (A=3 is the usual "Lanczos-3").
+type lanczosResampler struct {
+	A float64
+}
+
+func (r lanczosResampler) Name() string     { return "lanczos3" }
+func (r lanczosResampler) Support() float64 { return r.A }
+func (r lanczosResampler) Weight(x float64) float64 {
+	if x == 0 {
+		return 1.0
+	}
+	if math.Abs(x) >= r.A {
+		return 0.0
+	}
+	piX := math.Pi * x
+	return r.A * math.Sin(piX) * math.Sin(piX/r.A) / (piX * piX)
+}
+
+// Preconfigured resamplers covering the common kernel choices.
+var (
+	Box      Resampler = boxResampler{}
+	Triangle Resampler = triangleResampler{}
+	Bicubic  Resampler = bicubicResampler{B: 1.0 / 3, C: 1.0 / 3}
+	Gaussian Resampler = gaussianResampler{Sigma: 0.5}
+	Lanczos3 Resampler = lanczosResampler{A: 3}
+)
+
+// pixelCoeffs holds one output pixel's precomputed kernel contributions:
+// offsets[i] is the relative pixel delta of a contributing neighbor, and
+// coeffs[i] is the kernel's weight at that delta. filterLength is len(offsets),
+// kept alongside for parity with the fixed-length tables typical
+// image-resize libraries precompute.
+type pixelCoeffs struct {
+	offsets      []int
+	coeffs       []float64
+	filterLength int
+}
+
+// buildResampleTable precomputes, for every output pixel index in
+// [0, outputSize), the kernel offsets and coefficients within the kernel's
+// support. The kernel is shift-invariant so every index shares the same
+// offsets, except near the edges where out-of-range offsets are dropped.
+func buildResampleTable(outputSize int, kernel Resampler) []pixelCoeffs {
+	support := kernel.Support()
+	radius := int(math.Ceil(support))
+
+	var baseOffsets []int
+	var baseWeights []float64
+	for d := -radius; d <= radius; d++ {
+		x := float64(d)
+		if math.Abs(x) > support {
+			continue
+		}
+		w := kernel.Weight(x)
+		if w == 0 {
+			continue
+		}
+		baseOffsets = append(baseOffsets, d)
+		baseWeights = append(baseWeights, w)
+	}
+
+	table := make([]pixelCoeffs, outputSize)
+	for i := range table {
+		var offsets []int
+		var coeffs []float64
+		for j, d := range baseOffsets {
+			idx := i + d
+			if idx < 0 || idx >= outputSize {
+				continue
+			}
+			offsets = append(offsets, d)
+			coeffs = append(coeffs, baseWeights[j])
+		}
+		table[i] = pixelCoeffs{offsets: offsets, coeffs: coeffs, filterLength: len(offsets)}
+	}
+	return table
+}
+
+// downsampleComplexFiltered is like downsampleComplexSerial but instead of an
+// unweighted box-filter mean per pixel bucket, it reconstructs each occupied
+// output pixel from a weighted combination of its neighboring buckets using
+// kernel's precomputed coefficient table. Using Box gives each occupied pixel
+// the plain mean of the links in its bucket; this is not the same output as
+// downsampleComplexSerial, which groups consecutive links lying within
+// pixelSpreadThreshold of each other. Triangle/Bicubic/Gaussian/Lanczos3 trade a little
+// extra work for substantially smoother renders without bumping outputSize.
+func downsampleComplexFiltered(links []complex128, outputSize int, kernel Resampler, aggressiveness float64, debug bool) []complex128 {
+	if len(links) == 0 {
+		return links
+	}
+
+	if debug {
+		log.Printf("Starting downsampleComplexFiltered with %d links, kernel %s, output size %d (aggressiveness: %.2f)",
+			len(links), kernel.Name(), outputSize, aggressiveness)
+	}
+
+	// Determine view bounds from the links.
+	minX, maxX := real(links[0]), real(links[0])
+	minY, maxY := imag(links[0]), imag(links[0])
+	for _, link := range links {
+		x := real(link)
+		y := imag(link)
+		if x < minX {
+			minX = x
+		}
+		if x > maxX {
+			maxX = x
+		}
+		if y < minY {
+			minY = y
+		}
+		if y > maxY {
+			maxY = y
+		}
+	}
+
+	// Calculate relative distance between points
+	maxRange := math.Max(maxX-minX, maxY-minY)
+	baseRange := math.Max(0.01, maxRange)
+	relativeSpread := maxRange / baseRange
+
+	maxRelativeSpread := 0.0001
+	if aggressiveness > 0.0 {
+		maxRelativeSpread *= math.Pow(5, aggressiveness)
+	}
+	if aggressiveness > 3.5 {
+		t := (aggressiveness - 3.5) / 0.5
+		maxRelativeSpread = 0.03 + (0.02 * t)
+	}
+
+	if relativeSpread <= maxRelativeSpread {
+		var sum complex128
+		for _, link := range links {
+			sum += link
+		}
+		avg := sum / complex(float64(len(links)), 0)
+		return []complex128{avg}
+	}
+
+	interpolationThreshold := 1.1 * math.Pow(2.5, aggressiveness)
+	if aggressiveness > 3.5 {
+		t := (aggressiveness - 3.5) / 0.5
+		interpolationThreshold = 55.0 + (20.0 * t)
+	}
+
+	// clampPixel keeps coordinates valid as indices into coeffTable (which only has
+	// outputSize entries): math.Round(1.0*outputSize) == outputSize for a link sitting
+	// exactly on maxX/maxY, which is otherwise one past the last valid index.
+	clampPixel := func(p int) int {
+		if p < 0 {
+			return 0
+		}
+		if p > outputSize-1 {
+			return outputSize - 1
+		}
+		return p
+	}
+
+	pixelForLink := func(link complex128) (int, int) {
+		normalizedX := (real(link) - minX) / (maxX - minX)
+		normalizedY := (imag(link) - minY) / (maxY - minY)
+		px := clampPixel(int(math.Round(normalizedX * float64(outputSize))))
+		py := clampPixel(int(math.Round(normalizedY * float64(outputSize))))
+		return px, py
+	}
+
+	// Bucket every link by its exact pixel coordinate so the kernel can
+	// gather contributions from neighboring buckets, preserving the order in
+	// which buckets are first visited along the trace.
+	type bucketKey struct{ x, y int }
+	type bucket struct {
+		sum   complex128
+		count int
+	}
+	buckets := make(map[bucketKey]*bucket, len(links))
+	order := make([]bucketKey, 0, len(links))
+	for _, link := range links {
+		px, py := pixelForLink(link)
+		key := bucketKey{px, py}
+		b, ok := buckets[key]
+		if !ok {
+			b = &bucket{}
+			buckets[key] = b
+			order = append(order, key)
+		}
+		b.sum += link
+		b.count++
+	}
+
+	coeffTable := buildResampleTable(outputSize, kernel)
+
+	downsampled := make([]complex128, 0, len(order))
+	var lastPixel bucketKey
+	for oi, key := range order {
+		xc := coeffTable[key.x]
+		yc := coeffTable[key.y]
+
+		var sum complex128
+		var coeffSum float64
+		for xi, xOffset := range xc.offsets {
+			nx := key.x + xOffset
+			for yi, yOffset := range yc.offsets {
+				neighbor, ok := buckets[bucketKey{nx, key.y + yOffset}]
+				if !ok {
+					continue
+				}
+				coeff := xc.coeffs[xi] * yc.coeffs[yi]
+				neighborAvg := neighbor.sum / complex(float64(neighbor.count), 0)
+				sum += complex(coeff, 0) * neighborAvg
+				coeffSum += coeff
+			}
+		}
+		value := sum / complex(coeffSum, 0)
+
+		if oi > 0 {
+			dx := key.x - lastPixel.x
+			dy := key.y - lastPixel.y
+			pixelGap := math.Sqrt(float64(dx*dx + dy*dy))
+			if pixelGap > interpolationThreshold {
+				steps := int(pixelGap / math.Pow(2, math.Min(aggressiveness, 3.5)))
+				if aggressiveness > 3.5 {
+					t := (aggressiveness - 3.5) / 0.5
+					steps = int(float64(steps) * (1.0 - (0.5 * t)))
+				}
+				prev := downsampled[len(downsampled)-1]
+				for s := 1; s <= steps; s++ {
+					t := float64(s) / float64(steps+1)
+					downsampled = append(downsampled, interpolatePoint(prev, value, t, InterpLinear))
+				}
+			}
+		}
+
+		downsampled = append(downsampled, value)
+		lastPixel = key
+	}
+
+	if debug {
+		log.Printf("Filtered-downsampled %d points to %d points using %s kernel", len(links), len(downsampled), kernel.Name())
+	}
+	return downsampled
+}
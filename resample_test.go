@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+// TestDownsampleComplexFilteredNoPanicAtBounds guards against a regression where links sitting
+// exactly on the view's maxX/maxY round up to a pixel coordinate of outputSize - one past the
+// last valid index into coeffTable - and panic.
+func TestDownsampleComplexFilteredNoPanicAtBounds(t *testing.T) {
+	for _, kernel := range []Resampler{Box, Triangle, Bicubic, Gaussian, Lanczos3} {
+		links := zetaLikeTrace(20000)
+		if got := downsampleComplexFiltered(links, 512, kernel, 1.0, false); len(got) == 0 {
+			t.Errorf("kernel %s: expected some output points, got none", kernel.Name())
+		}
+	}
+}
+
+func TestBuildResampleTableStaysInBounds(t *testing.T) {
+	const outputSize = 64
+	for _, kernel := range []Resampler{Box, Triangle, Bicubic, Gaussian, Lanczos3} {
+		table := buildResampleTable(outputSize, kernel)
+		if len(table) != outputSize {
+			t.Fatalf("kernel %s: expected table of length %d, got %d", kernel.Name(), outputSize, len(table))
+		}
+		for i, pc := range table {
+			for _, offset := range pc.offsets {
+				idx := i + offset
+				if idx < 0 || idx >= outputSize {
+					t.Errorf("kernel %s: entry %d has out-of-range offset %d (index %d)", kernel.Name(), i, offset, idx)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// Rect is a view-bounds rectangle in the complex plane, used wherever bounds must be supplied
+// rather than discovered by scanning an in-memory slice.
+type Rect struct {
+	MinX, MaxX, MinY, MaxY float64
+}
+
+// streamGroup mirrors the groupData used by the in-memory downsamplers.
+type streamGroup struct {
+	sum      complex128
+	count    int
+	pixelX   int
+	pixelY   int
+	lastLink complex128
+}
+
+// StreamDownsampler applies the same pixel-bucket grouping and gap interpolation as
+// downsampleComplexSerial to a stream of links fed in one at a time, so a caller never needs
+// the whole `[]complex128` resident in memory. Because grouping needs view bounds up front and
+// a stream can only be read once, bounds must be supplied by the caller; see DownsampleReader
+// for the single-pass entry point and DownsampleReaderTwoPass for one that discovers them.
+type StreamDownsampler struct {
+	outputSize             int
+	bounds                 Rect
+	aggressiveness         float64
+	mode                   InterpMode
+	pixelSpreadThreshold   float64
+	interpolationThreshold float64
+
+	started      bool
+	currentGroup streamGroup
+	pending      []complex128
+}
+
+// NewStreamingDownsampler creates a StreamDownsampler for links known to fall within bounds.
+// aggressiveness has the same meaning as in downsampleComplexSerial (0.0 = minimal reduction;
+// larger values reduce more, with the thresholds following a separate schedule from 3.5 to 4.0).
+func NewStreamingDownsampler(outputSize int, bounds Rect, aggressiveness float64) *StreamDownsampler {
+	d := &StreamDownsampler{
+		outputSize:     outputSize,
+		bounds:         bounds,
+		aggressiveness: aggressiveness,
+		mode:           InterpLinear,
+	}
+
+	d.pixelSpreadThreshold = 1.0
+	if aggressiveness > 0.0 {
+		d.pixelSpreadThreshold += aggressiveness * 2.0
+	}
+
+	d.interpolationThreshold = 1.1 * math.Pow(2.5, aggressiveness)
+	if aggressiveness > 3.5 {
+		t := (aggressiveness - 3.5) / 0.5
+		d.interpolationThreshold = 55.0 + (20.0 * t)
+	}
+
+	return d
+}
+
+func (d *StreamDownsampler) pixelFor(link complex128) (int, int) {
+	normalizedX := (real(link) - d.bounds.MinX) / (d.bounds.MaxX - d.bounds.MinX)
+	normalizedY := (imag(link) - d.bounds.MinY) / (d.bounds.MaxY - d.bounds.MinY)
+	px := int(math.Round(normalizedX * float64(d.outputSize)))
+	py := int(math.Round(normalizedY * float64(d.outputSize)))
+	return px, py
+}
+
+// Write feeds the next link in the stream into the downsampler. Whenever a group completes,
+// its average (and any gap-interpolated points leading to the next group) are appended to an
+// internal buffer, retrievable with Flush.
+func (d *StreamDownsampler) Write(link complex128) {
+	px, py := d.pixelFor(link)
+
+	if !d.started {
+		d.started = true
+		d.currentGroup = streamGroup{sum: link, count: 1, pixelX: px, pixelY: py, lastLink: link}
+		return
+	}
+
+	if px == d.currentGroup.pixelX && py == d.currentGroup.pixelY ||
+		(math.Abs(float64(px-d.currentGroup.pixelX)) <= d.pixelSpreadThreshold &&
+			math.Abs(float64(py-d.currentGroup.pixelY)) <= d.pixelSpreadThreshold) {
+		d.currentGroup.sum += link
+		d.currentGroup.count++
+		d.currentGroup.lastLink = link
+		return
+	}
+
+	avg := d.currentGroup.sum / complex(float64(d.currentGroup.count), 0)
+	d.pending = append(d.pending, avg)
+
+	dx := px - d.currentGroup.pixelX
+	dy := py - d.currentGroup.pixelY
+	pixelGap := math.Sqrt(float64(dx*dx + dy*dy))
+	if pixelGap > d.interpolationThreshold {
+		steps := int(pixelGap / math.Pow(2, math.Min(d.aggressiveness, 3.5)))
+		if d.aggressiveness > 3.5 {
+			t := (d.aggressiveness - 3.5) / 0.5
+			steps = int(float64(steps) * (1.0 - (0.5 * t)))
+		}
+		for s := 1; s <= steps; s++ {
+			t := float64(s) / float64(steps+1)
+			d.pending = append(d.pending, interpolatePoint(d.currentGroup.lastLink, link, t, d.mode))
+		}
+	}
+
+	d.currentGroup = streamGroup{sum: link, count: 1, pixelX: px, pixelY: py, lastLink: link}
+}
+
+// Flush finalizes the in-progress group (if any) and returns every output point produced since
+// construction or the last Flush call. It is meant to be called once after the final Write.
+func (d *StreamDownsampler) Flush() []complex128 {
+	if d.started {
+		avg := d.currentGroup.sum / complex(float64(d.currentGroup.count), 0)
+		d.pending = append(d.pending, avg)
+		d.started = false
+	}
+	out := d.pending
+	d.pending = nil
+	return out
+}
+
+// DownsampleReader decodes a stream of complex128 values from r - each one a little-endian
+// float64 real part followed by a little-endian float64 imaginary part - and downsamples them
+// incrementally via StreamDownsampler, never holding the full decoded stream in memory.
+func DownsampleReader(r io.Reader, outputSize int, bounds Rect, aggressiveness float64) ([]complex128, error) {
+	d := NewStreamingDownsampler(outputSize, bounds, aggressiveness)
+
+	buf := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("downsample: reading link: %w", err)
+		}
+		re := math.Float64frombits(binary.LittleEndian.Uint64(buf[0:8]))
+		im := math.Float64frombits(binary.LittleEndian.Uint64(buf[8:16]))
+		d.Write(complex(re, im))
+	}
+
+	return d.Flush(), nil
+}
+
+// DownsampleReaderTwoPass discovers view bounds from r before downsampling, for callers that
+// don't know them up front. Since bounds require seeing every link and r may not be seekable,
+// the stream is first buffered to a temp file on disk rather than mmap'd: this keeps the
+// implementation dependency-free (no platform-specific mmap syscalls) at the cost of one extra
+// sequential copy, while still bounding memory use to the temp file rather than RAM. The file is
+// then read twice - once to compute bounds, once through DownsampleReader - so this function
+// adds no grouping logic of its own.
+func DownsampleReaderTwoPass(r io.Reader, outputSize int, aggressiveness float64) ([]complex128, error) {
+	tmp, err := os.CreateTemp("", "zeta-lab-stream-*.bin")
+	if err != nil {
+		return nil, fmt.Errorf("downsample: creating temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		return nil, fmt.Errorf("downsample: buffering stream: %w", err)
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("downsample: seeking temp file: %w", err)
+	}
+	bounds, err := scanStreamBounds(tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("downsample: seeking temp file: %w", err)
+	}
+	return DownsampleReader(tmp, outputSize, bounds, aggressiveness)
+}
+
+// scanStreamBounds reads every link in r once to compute its view bounds.
+func scanStreamBounds(r io.Reader) (Rect, error) {
+	var bounds Rect
+	first := true
+
+	buf := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return Rect{}, fmt.Errorf("downsample: scanning bounds: %w", err)
+		}
+		re := math.Float64frombits(binary.LittleEndian.Uint64(buf[0:8]))
+		im := math.Float64frombits(binary.LittleEndian.Uint64(buf[8:16]))
+
+		if first {
+			bounds = Rect{MinX: re, MaxX: re, MinY: im, MaxY: im}
+			first = false
+			continue
+		}
+		if re < bounds.MinX {
+			bounds.MinX = re
+		}
+		if re > bounds.MaxX {
+			bounds.MaxX = re
+		}
+		if im < bounds.MinY {
+			bounds.MinY = im
+		}
+		if im > bounds.MaxY {
+			bounds.MaxY = im
+		}
+	}
+
+	return bounds, nil
+}
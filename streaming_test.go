@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func encodeLinks(links []complex128) []byte {
+	buf := make([]byte, 0, len(links)*16)
+	for _, link := range links {
+		var re, im [8]byte
+		binary.LittleEndian.PutUint64(re[:], math.Float64bits(real(link)))
+		binary.LittleEndian.PutUint64(im[:], math.Float64bits(imag(link)))
+		buf = append(buf, re[:]...)
+		buf = append(buf, im[:]...)
+	}
+	return buf
+}
+
+func linkBounds(links []complex128) Rect {
+	bounds := Rect{MinX: real(links[0]), MaxX: real(links[0]), MinY: imag(links[0]), MaxY: imag(links[0])}
+	for _, link := range links {
+		x, y := real(link), imag(link)
+		bounds.MinX = math.Min(bounds.MinX, x)
+		bounds.MaxX = math.Max(bounds.MaxX, x)
+		bounds.MinY = math.Min(bounds.MinY, y)
+		bounds.MaxY = math.Max(bounds.MaxY, y)
+	}
+	return bounds
+}
+
+func TestDownsampleReaderMatchesSerial(t *testing.T) {
+	links := zetaLikeTrace(20000)
+	bounds := linkBounds(links)
+
+	want := downsampleComplexSerial(links, 512, 1.0, InterpLinear, false)
+
+	got, err := DownsampleReader(bytes.NewReader(encodeLinks(links)), 512, bounds, 1.0)
+	if err != nil {
+		t.Fatalf("DownsampleReader: %v", err)
+	}
+
+	if len(want) != len(got) {
+		t.Fatalf("point count mismatch: serial=%d streaming=%d", len(want), len(got))
+	}
+	for i := range want {
+		if want[i] != got[i] {
+			t.Fatalf("point %d differs: serial=%v streaming=%v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestDownsampleReaderTwoPassDiscoversBounds(t *testing.T) {
+	links := zetaLikeTrace(5000)
+
+	want, err := DownsampleReader(bytes.NewReader(encodeLinks(links)), 256, linkBounds(links), 0.5)
+	if err != nil {
+		t.Fatalf("DownsampleReader: %v", err)
+	}
+
+	got, err := DownsampleReaderTwoPass(bytes.NewReader(encodeLinks(links)), 256, 0.5)
+	if err != nil {
+		t.Fatalf("DownsampleReaderTwoPass: %v", err)
+	}
+
+	if len(want) != len(got) {
+		t.Fatalf("point count mismatch: explicit-bounds=%d two-pass=%d", len(want), len(got))
+	}
+	for i := range want {
+		if want[i] != got[i] {
+			t.Fatalf("point %d differs: explicit-bounds=%v two-pass=%v", i, want[i], got[i])
+		}
+	}
+}
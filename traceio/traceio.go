@@ -0,0 +1,289 @@
+// Package traceio persists downsampled zeta traces to disk so expensive link computations can
+// be cached and re-rendered at different output sizes or aggressiveness levels without
+// recomputing the underlying links.
+//
+// The on-disk layout is modeled on the OOMMF/OVF format: a text header of "# Key: Value" lines,
+// a "# Begin: Data <kind>" marker, the point data itself, and a matching "# End: Data <kind>"
+// marker. WriteTrace always emits the Binary 8 variant; ReadTrace additionally understands Text
+// and Binary 4 payloads so traces produced by other OVF-style tooling can be read back too.
+package traceio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TraceMeta is the metadata persisted alongside a downsampled trace's points.
+type TraceMeta struct {
+	MinX, MaxX, MinY, MaxY float64 // view bounds the trace was downsampled within
+	OutputSize             int     // output image size used when downsampling
+	Aggressiveness         float64 // aggressiveness used when downsampling
+	Kernel                 string  // resampling kernel name, e.g. "lanczos3"
+	Source                 map[string]string // source computation parameters (e.g. s-value, iterations)
+}
+
+// WriteTrace writes pts and meta to w using the Binary 8 (little-endian float64 pairs) payload.
+func WriteTrace(w io.Writer, pts []complex128, meta TraceMeta) error {
+	bw := bufio.NewWriter(w)
+
+	header := func(key, value string) error {
+		_, err := fmt.Fprintf(bw, "# %s: %s\n", key, value)
+		return err
+	}
+
+	fields := []struct{ key, value string }{
+		{"OOMMF", "zeta-lab trace"},
+		{"MinX", strconv.FormatFloat(meta.MinX, 'g', -1, 64)},
+		{"MaxX", strconv.FormatFloat(meta.MaxX, 'g', -1, 64)},
+		{"MinY", strconv.FormatFloat(meta.MinY, 'g', -1, 64)},
+		{"MaxY", strconv.FormatFloat(meta.MaxY, 'g', -1, 64)},
+		{"OutputSize", strconv.Itoa(meta.OutputSize)},
+		{"Aggressiveness", strconv.FormatFloat(meta.Aggressiveness, 'g', -1, 64)},
+		{"Kernel", meta.Kernel},
+		{"PointCount", strconv.Itoa(len(pts))},
+	}
+	for _, f := range fields {
+		if err := header(f.key, f.value); err != nil {
+			return fmt.Errorf("traceio: writing header: %w", err)
+		}
+	}
+
+	sourceKeys := make([]string, 0, len(meta.Source))
+	for k := range meta.Source {
+		sourceKeys = append(sourceKeys, k)
+	}
+	sort.Strings(sourceKeys)
+	for _, k := range sourceKeys {
+		if err := header("Source."+k, meta.Source[k]); err != nil {
+			return fmt.Errorf("traceio: writing source header: %w", err)
+		}
+	}
+
+	if _, err := fmt.Fprintln(bw, "# Begin: Data Binary 8"); err != nil {
+		return fmt.Errorf("traceio: writing data marker: %w", err)
+	}
+
+	var buf [16]byte
+	for _, p := range pts {
+		binary.LittleEndian.PutUint64(buf[0:8], math.Float64bits(real(p)))
+		binary.LittleEndian.PutUint64(buf[8:16], math.Float64bits(imag(p)))
+		if _, err := bw.Write(buf[:]); err != nil {
+			return fmt.Errorf("traceio: writing point: %w", err)
+		}
+	}
+
+	if _, err := fmt.Fprintln(bw, "# End: Data Binary 8"); err != nil {
+		return fmt.Errorf("traceio: writing end marker: %w", err)
+	}
+
+	return bw.Flush()
+}
+
+// ReadTrace reads a trace written by WriteTrace, or one produced by another OVF-style tool
+// using the Text or Binary 4 payload variants, autodetected from the "# Begin: Data <kind>"
+// marker.
+func ReadTrace(r io.Reader) ([]complex128, TraceMeta, error) {
+	br := bufio.NewReader(r)
+	meta := TraceMeta{Source: map[string]string{}}
+	pointCount := -1
+
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && line == "" {
+			return nil, TraceMeta{}, fmt.Errorf("traceio: unexpected EOF before data section")
+		}
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "#") {
+			return nil, TraceMeta{}, fmt.Errorf("traceio: malformed header line %q", line)
+		}
+		content := strings.TrimSpace(strings.TrimPrefix(line, "#"))
+
+		if strings.HasPrefix(content, "Begin: Data") {
+			kind := strings.TrimSpace(strings.TrimPrefix(content, "Begin: Data"))
+			pts, err := readTraceBody(br, kind, pointCount)
+			if err != nil {
+				return nil, TraceMeta{}, err
+			}
+			return pts, meta, nil
+		}
+
+		sep := strings.Index(content, ":")
+		if sep < 0 {
+			return nil, TraceMeta{}, fmt.Errorf("traceio: malformed header line %q", line)
+		}
+		key := strings.TrimSpace(content[:sep])
+		value := strings.TrimSpace(content[sep+1:])
+
+		switch {
+		case key == "MinX":
+			meta.MinX, _ = strconv.ParseFloat(value, 64)
+		case key == "MaxX":
+			meta.MaxX, _ = strconv.ParseFloat(value, 64)
+		case key == "MinY":
+			meta.MinY, _ = strconv.ParseFloat(value, 64)
+		case key == "MaxY":
+			meta.MaxY, _ = strconv.ParseFloat(value, 64)
+		case key == "OutputSize":
+			meta.OutputSize, _ = strconv.Atoi(value)
+		case key == "Aggressiveness":
+			meta.Aggressiveness, _ = strconv.ParseFloat(value, 64)
+		case key == "Kernel":
+			meta.Kernel = value
+		case key == "PointCount":
+			pointCount, _ = strconv.Atoi(value)
+		case strings.HasPrefix(key, "Source."):
+			meta.Source[strings.TrimPrefix(key, "Source.")] = value
+		}
+	}
+}
+
+func readTraceBody(br *bufio.Reader, kind string, pointCount int) ([]complex128, error) {
+	switch kind {
+	case "Text":
+		return readTraceText(br, pointCount)
+	case "Binary 4":
+		return readTraceBinary(br, pointCount, 4)
+	case "Binary 8":
+		return readTraceBinary(br, pointCount, 8)
+	default:
+		return nil, fmt.Errorf("traceio: unsupported data section %q", kind)
+	}
+}
+
+func readTraceBinary(br *bufio.Reader, pointCount, width int) ([]complex128, error) {
+	buf := make([]byte, width)
+
+	decode := func() (float64, error) {
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return 0, err
+		}
+		if width == 4 {
+			return float64(math.Float32frombits(binary.LittleEndian.Uint32(buf))), nil
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(buf)), nil
+	}
+
+	if pointCount >= 0 {
+		// A known count lets every point be read unconditionally, so there's no need to
+		// peek for the end marker - which matters, since that peek only checks for a
+		// leading '#' byte (0x23), and roughly 1/256 of real float64 values share it.
+		pts := make([]complex128, pointCount)
+		for i := range pts {
+			re, err := decode()
+			if err != nil {
+				return nil, fmt.Errorf("traceio: reading real part: %w", err)
+			}
+			im, err := decode()
+			if err != nil {
+				return nil, fmt.Errorf("traceio: reading imaginary part: %w", err)
+			}
+			pts[i] = complex(re, im)
+		}
+		if err := consumeEndMarker(br); err != nil {
+			return nil, err
+		}
+		return pts, nil
+	}
+
+	// No PointCount header: fall back to peeking for the end marker, point by point.
+	var pts []complex128
+	for {
+		if peek, err := br.Peek(1); err == nil && peek[0] == '#' {
+			break
+		}
+		re, err := decode()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("traceio: reading real part: %w", err)
+		}
+		im, err := decode()
+		if err != nil {
+			return nil, fmt.Errorf("traceio: reading imaginary part: %w", err)
+		}
+		pts = append(pts, complex(re, im))
+	}
+
+	if err := consumeEndMarker(br); err != nil {
+		return nil, err
+	}
+	return pts, nil
+}
+
+func readTraceText(br *bufio.Reader, pointCount int) ([]complex128, error) {
+	parsePoint := func(trimmed string) (complex128, error) {
+		fields := strings.Fields(trimmed)
+		if len(fields) != 2 {
+			return 0, fmt.Errorf("traceio: malformed text data line %q", trimmed)
+		}
+		re, errRe := strconv.ParseFloat(fields[0], 64)
+		im, errIm := strconv.ParseFloat(fields[1], 64)
+		if errRe != nil || errIm != nil {
+			return 0, fmt.Errorf("traceio: malformed text data line %q", trimmed)
+		}
+		return complex(re, im), nil
+	}
+
+	if pointCount >= 0 {
+		pts := make([]complex128, 0, pointCount)
+		for len(pts) < pointCount {
+			line, err := br.ReadString('\n')
+			trimmed := strings.TrimSpace(line)
+			if trimmed != "" {
+				p, perr := parsePoint(trimmed)
+				if perr != nil {
+					return nil, perr
+				}
+				pts = append(pts, p)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("traceio: reading text data: %w", err)
+			}
+		}
+		if err := consumeEndMarker(br); err != nil {
+			return nil, err
+		}
+		return pts, nil
+	}
+
+	// No PointCount header: fall back to scanning line by line for the end marker.
+	var pts []complex128
+	for {
+		line, err := br.ReadString('\n')
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "# End: Data") {
+			return pts, nil
+		}
+		if trimmed != "" {
+			p, perr := parsePoint(trimmed)
+			if perr != nil {
+				return nil, perr
+			}
+			pts = append(pts, p)
+		}
+		if err != nil {
+			if err == io.EOF {
+				return nil, fmt.Errorf("traceio: missing end marker")
+			}
+			return nil, fmt.Errorf("traceio: reading text data: %w", err)
+		}
+	}
+}
+
+func consumeEndMarker(br *bufio.Reader) error {
+	line, err := br.ReadString('\n')
+	if err != nil && line == "" {
+		return fmt.Errorf("traceio: missing end marker: %w", err)
+	}
+	if !strings.HasPrefix(strings.TrimSpace(line), "# End: Data") {
+		return fmt.Errorf("traceio: expected end marker, got %q", strings.TrimSpace(line))
+	}
+	return nil
+}